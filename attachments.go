@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/antihax/optional"
+	qase "go.qase.io/client"
+)
+
+// uploadAttachmentSet uploads each distinct file path once and returns a
+// path -> Qase attachment hash map, so a file referenced from both a
+// result's flattened Attachments list and one of its steps is only
+// uploaded (and hashed) a single time.
+func uploadAttachmentSet(paths []string) map[string]string {
+	hashes := make(map[string]string, len(paths))
+	for _, path := range paths {
+		if _, ok := hashes[path]; ok {
+			continue
+		}
+		hash, err := uploadAttachment(path)
+		if err != nil {
+			log.Printf("Error uploading attachment %q: %v", path, err)
+			continue
+		}
+		hashes[path] = hash
+	}
+	return hashes
+}
+
+// attachmentHashesFor looks up the uploaded hash for each path, in order,
+// dropping any path that failed to upload.
+func attachmentHashesFor(paths []string, hashes map[string]string) (result []string) {
+	for _, path := range paths {
+		if hash, ok := hashes[path]; ok {
+			result = append(result, hash)
+		}
+	}
+	return
+}
+
+func uploadAttachment(path string) (hash string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err = fmt.Errorf("error opening attachment file: %v", err)
+		return
+	}
+	defer file.Close()
+
+	resp, httpResp, err := qaseClient.AttachmentsApi.UploadAttachment(ctx, config.QaseProject, &qase.AttachmentsApiUploadAttachmentOpts{
+		File: optional.NewInterface([]*os.File{file}),
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to upload attachment: %v", err)
+		return
+	}
+	if httpResp.StatusCode != 200 {
+		err = fmt.Errorf("failed to upload attachment, status code: %v", httpResp.StatusCode)
+		return
+	}
+	if len(resp.Result) == 0 {
+		err = fmt.Errorf("upload attachment response has no result")
+		return
+	}
+
+	hash = resp.Result[0].Hash
+	return
+}
+
+// buildQaseResultSteps converts the parsed StepResult list into the
+// qase.ResultCreateSteps the Results API expects, looking up each step's
+// attachment hashes from hashesByPath (the result-level upload already
+// performed) instead of re-uploading them. hashesByPath is nil in dry-run
+// mode, in which case the step's raw file paths are logged as-is.
+func buildQaseResultSteps(steps []StepResult, hashesByPath map[string]string) []qase.ResultCreateSteps {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	qaseSteps := make([]qase.ResultCreateSteps, 0, len(steps))
+	for i, step := range steps {
+		attachments := step.Attachments
+		if hashesByPath != nil {
+			attachments = attachmentHashesFor(step.Attachments, hashesByPath)
+		}
+
+		qaseSteps = append(qaseSteps, qase.ResultCreateSteps{
+			Position:    int32(i + 1),
+			Status:      step.Status,
+			Comment:     renderStepsMarkdown([]StepResult{step}),
+			Attachments: attachments,
+		})
+	}
+	return qaseSteps
+}
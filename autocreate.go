@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	qase "go.qase.io/client"
+)
+
+// qaseMapping maps a test's fully-qualified name (suite path + "::" + test
+// name) to the Qase case ID --auto-create created for it, so that re-runs
+// of the same suite stay stable instead of creating duplicate cases.
+type qaseMapping map[string]int64
+
+// resolveAutoCreatedCases fills in TestCaseId for every result missing a
+// Q-<id> tag: first by consulting the mapping file, then by creating a new
+// Qase case. The mapping file is updated with any newly created cases.
+// Results that still can't be resolved are dropped, matching the previous
+// tag-not-found behavior.
+func resolveAutoCreatedCases(results []TestCaseResult) ([]TestCaseResult, error) {
+	mapping, err := loadQaseMapping(config.MappingFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading mapping file: %v", err)
+	}
+
+	suiteIds := make(map[string]int64)
+	resolved := make([]TestCaseResult, 0, len(results))
+	changed := false
+
+	for _, result := range results {
+		if result.TestCaseId != 0 {
+			resolved = append(resolved, result)
+			continue
+		}
+
+		key := qaseMappingKey(result.SuiteName, result.TestName)
+		if caseId, ok := mapping[key]; ok {
+			log.Printf("Using mapped case ID %d for %q", caseId, key)
+			result.TestCaseId = caseId
+			resolved = append(resolved, result)
+			continue
+		}
+
+		caseId, err := createQaseCaseForTest(result, suiteIds)
+		if err != nil {
+			log.Printf("Error auto-creating case for %q: %v", key, err)
+			continue
+		}
+
+		mapping[key] = caseId
+		changed = true
+		result.TestCaseId = caseId
+		resolved = append(resolved, result)
+	}
+
+	if changed {
+		if err := saveQaseMapping(config.MappingFile, mapping); err != nil {
+			return resolved, fmt.Errorf("error saving mapping file: %v", err)
+		}
+	}
+
+	return resolved, nil
+}
+
+func qaseMappingKey(suiteName string, testName string) string {
+	return suiteName + "::" + testName
+}
+
+func loadQaseMapping(path string) (qaseMapping, error) {
+	mapping := make(qaseMapping)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return mapping, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func saveQaseMapping(path string, mapping qaseMapping) error {
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// createQaseCaseForTest creates a Qase case for a test lacking a Q-<id> tag,
+// using its Robot name as the title, its <doc> as the description, and its
+// suite path resolved (or created) as the containing suite. suiteIds caches
+// suite name -> ID lookups across tests in the same suite.
+func createQaseCaseForTest(result TestCaseResult, suiteIds map[string]int64) (caseId int64, err error) {
+	var suiteId int64
+	if result.SuiteName != "" {
+		var ok bool
+		suiteId, ok = suiteIds[result.SuiteName]
+		if !ok {
+			suiteId, err = resolveOrCreateQaseSuite(result.SuiteName)
+			if err != nil {
+				return 0, fmt.Errorf("error resolving suite %q: %v", result.SuiteName, err)
+			}
+			suiteIds[result.SuiteName] = suiteId
+		}
+	}
+
+	description := result.Doc
+	if len(result.Tags) > 0 {
+		description = strings.TrimSpace(description + "\n\nTags: " + strings.Join(result.Tags, ", "))
+	}
+
+	qaseResp, httpResp, err := qaseClient.CasesApi.CreateCase(ctx, qase.TestCaseCreate{
+		Title:       result.TestName,
+		Description: description,
+		SuiteId:     suiteId,
+	}, config.QaseProject)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create case: %v", err)
+	}
+	if httpResp.StatusCode != 200 {
+		return 0, fmt.Errorf("failed to create case, status code: %v", httpResp.StatusCode)
+	}
+
+	caseId = qaseResp.Result.Id
+	log.Printf("Created Qase case %d for %q", caseId, result.TestName)
+	return
+}
+
+// resolveOrCreateQaseSuite finds a suite whose title matches suiteName,
+// creating it if it doesn't exist yet.
+func resolveOrCreateQaseSuite(suiteName string) (suiteId int64, err error) {
+	qaseResp, httpResp, err := qaseClient.SuitesApi.GetSuites(ctx, config.QaseProject, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list suites: %v", err)
+	}
+	if httpResp.StatusCode != 200 {
+		return 0, fmt.Errorf("failed to list suites, status code: %v", httpResp.StatusCode)
+	}
+
+	for _, suite := range qaseResp.Result.Entities {
+		if suite.Title == suiteName {
+			return suite.Id, nil
+		}
+	}
+
+	createResp, httpResp, err := qaseClient.SuitesApi.CreateSuite(ctx, qase.SuiteCreate{
+		Title: suiteName,
+	}, config.QaseProject)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create suite: %v", err)
+	}
+	if httpResp.StatusCode != 200 {
+		return 0, fmt.Errorf("failed to create suite, status code: %v", httpResp.StatusCode)
+	}
+
+	return createResp.Result.Id, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	qase "go.qase.io/client"
+)
+
+// serveCmd exposes an XML-RPC endpoint compatible with Robot Framework's
+// Remote Listener v3 interface, so a run can stream results to Qase live
+// instead of waiting for output.xml.
+var serveCmd = &cobra.Command{
+	Use:     "serve",
+	Aliases: []string{"listen"},
+	Short:   "Start an XML-RPC listener compatible with Robot Framework's remote listener interface",
+	Long: `serve starts an XML-RPC server implementing the subset of Robot Framework's
+Remote Listener v3 interface needed to report results to Qase as a run
+progresses: start_suite, end_suite, start_test, end_test, and close.
+
+Point Robot Framework at it with:
+
+    robot --listener RemoteListener:127.0.0.1:8270 suite.robot
+
+(RemoteListener is the small XML-RPC forwarding listener shipped separately
+with Robot Framework; this command is the server it talks to.)
+`,
+	Args: cobra.NoArgs,
+	RunE: runServeCommand,
+}
+
+// listenerState tracks the run created for the current top-level suite and
+// how deeply nested start_suite/end_suite calls currently are, so the run is
+// only created on the outermost start_suite and completed on the matching
+// end_suite.
+type listenerState struct {
+	mu         sync.Mutex
+	runId      int32
+	suiteDepth int
+}
+
+var listener listenerState
+
+func init() {
+	serveCmd.Flags().StringP("listen", "l", ":8270", "Address to listen on for Robot Framework remote listener connections")
+	viper.BindPFlag("listen", serveCmd.Flags().Lookup("listen"))
+}
+
+func runServeCommand(cmd *cobra.Command, args []string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", listenerHTTPHandler)
+	mux.HandleFunc("/RPC2", listenerHTTPHandler)
+
+	log.Printf("Listening for Robot Framework remote listener connections on %s", config.Listen)
+	return http.ListenAndServe(config.Listen, mux)
+}
+
+func listenerHTTPHandler(w http.ResponseWriter, r *http.Request) {
+	method, params, err := decodeXmlRpcMethodCall(r.Body)
+	if err != nil {
+		writeXmlRpcResponse(w, "", err)
+		return
+	}
+
+	handler, ok := listenerMethods[method]
+	if !ok {
+		// Methods we don't act on (start_keyword, log_message, output_file,
+		// ...) are acknowledged and ignored.
+		writeXmlRpcResponse(w, "", nil)
+		return
+	}
+
+	result, err := handler(params)
+	if err != nil {
+		log.Printf("Error handling %s: %v", method, err)
+	}
+	writeXmlRpcResponse(w, result, err)
+}
+
+var listenerMethods = map[string]func(params []interface{}) (string, error){
+	"start_suite": listenerHandleStartSuite,
+	"end_suite":   listenerHandleEndSuite,
+	"end_test":    listenerHandleEndTest,
+	"close":       listenerHandleClose,
+}
+
+func listenerHandleStartSuite(params []interface{}) (string, error) {
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+
+	if listener.suiteDepth == 0 {
+		if config.DryRun {
+			log.Printf("[dry-run] Skipping run creation")
+		} else {
+			runId, err := resolveQaseRun(true)
+			if err != nil {
+				return "", fmt.Errorf("error creating run: %v", err)
+			}
+			listener.runId = runId
+		}
+	}
+	listener.suiteDepth++
+	return "", nil
+}
+
+func listenerHandleEndSuite(params []interface{}) (string, error) {
+	listener.mu.Lock()
+	listener.suiteDepth--
+	runId := listener.runId
+	atOutermost := listener.suiteDepth == 0
+	listener.mu.Unlock()
+
+	if !atOutermost || !config.Complete || config.DryRun {
+		return "", nil
+	}
+
+	if err := completeQaseRun(runId); err != nil {
+		return "", fmt.Errorf("error completing run: %v", err)
+	}
+	return "", nil
+}
+
+func listenerHandleEndTest(params []interface{}) (string, error) {
+	if len(params) < 2 {
+		return "", fmt.Errorf("end_test: expected (name, attributes), got %d params", len(params))
+	}
+	name, _ := params[0].(string)
+	attrs, _ := params[1].(map[string]interface{})
+
+	result, err := testCaseResultFromListenerAttrs(name, attrs)
+	if err != nil {
+		log.Printf("Skipping %q: %v", name, err)
+		return "", nil
+	}
+
+	batch := buildQaseResults([]TestCaseResult{result}, !config.DryRun)
+
+	if config.DryRun {
+		return "", logDryRunBatches([][]qase.ResultCreate{batch})
+	}
+
+	listener.mu.Lock()
+	runId := listener.runId
+	listener.mu.Unlock()
+
+	if err := submitResultBatches(runId, [][]qase.ResultCreate{batch}); err != nil {
+		return "", fmt.Errorf("error reporting %q: %v", name, err)
+	}
+	return "", nil
+}
+
+func listenerHandleClose(params []interface{}) (string, error) {
+	return "", nil
+}
+
+// testCaseResultFromListenerAttrs builds a TestCaseResult from the
+// attributes dict Robot Framework's end_test call passes: its Qase ID comes
+// from a `Q-<id>` tag, its status from "status", and its duration from
+// "elapsedtime" (milliseconds).
+func testCaseResultFromListenerAttrs(name string, attrs map[string]interface{}) (result TestCaseResult, err error) {
+	tags, _ := attrs["tags"].([]interface{})
+	for _, tag := range tags {
+		text, ok := tag.(string)
+		if !ok {
+			continue
+		}
+		if id, found := extractQaseId(text); found {
+			result.TestCaseId = id
+			break
+		}
+	}
+	if result.TestCaseId == 0 {
+		err = fmt.Errorf("cannot find Qase ID in tags")
+		return
+	}
+
+	status, _ := attrs["status"].(string)
+	switch status {
+	case "PASS":
+		result.Status = TEST_RESULT_STATUS_PASSED
+	case "SKIP":
+		result.Status = TEST_RESULT_STATUS_SKIPPED
+	default:
+		result.Status = TEST_RESULT_STATUS_FAILED
+	}
+
+	if elapsed, ok := attrs["elapsedtime"].(int64); ok {
+		result.TimeMs = elapsed
+	}
+
+	if message, ok := attrs["message"].(string); ok {
+		result.Stacktrace = message
+	}
+
+	return
+}
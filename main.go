@@ -3,12 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/beevik/etree"
@@ -19,22 +19,44 @@ import (
 
 type Config struct {
 	Filename     string
+	Format       string `mapstructure:"format"`
 	QaseApiToken string `mapstructure:"api_token"`
 	QaseProject  string `mapstructure:"project"`
 	QaseRunTitle string `mapstructure:"run_title"`
+	RunId        int32  `mapstructure:"run_id"`
+	ReuseRun     bool   `mapstructure:"reuse_run"`
+	Complete     bool   `mapstructure:"complete"`
+	BatchSize    int    `mapstructure:"batch_size"`
+	Concurrency  int    `mapstructure:"concurrency"`
+	DryRun       bool   `mapstructure:"dry_run"`
+	AutoCreate   bool   `mapstructure:"auto_create"`
+	MappingFile  string `mapstructure:"mapping_file"`
+	Listen       string `mapstructure:"listen"`
 }
 
 type TestCaseResult struct {
-	Package    string
-	TestCaseId int64
-	Status     string
-	Time       time.Time
-	TimeMs     int64
+	Package     string
+	TestCaseId  int64
+	Status      string
+	Time        time.Time
+	TimeMs      int64
+	Comment     string       // Markdown summary of keyword/step execution
+	Stacktrace  string       // Concatenated failure messages, if any
+	Steps       []StepResult // Keyword-level detail, Robot Framework only
+	Attachments []string     // Files referenced from log messages, absolute paths
+
+	// The fields below are only populated when the test has no Q-<id> tag,
+	// for --auto-create to use when creating a matching Qase case.
+	TestName  string
+	Doc       string
+	Tags      []string
+	SuiteName string
 }
 
 const (
-	TEST_RESULT_STATUS_PASSED = "passed"
-	TEST_RESULT_STATUS_FAILED = "failed"
+	TEST_RESULT_STATUS_PASSED  = "passed"
+	TEST_RESULT_STATUS_FAILED  = "failed"
+	TEST_RESULT_STATUS_SKIPPED = "skipped"
 
 	//
 	V6_TIME_PATTERN = "20060102 15:04:05.999"
@@ -59,26 +81,49 @@ This is an alternative to the Robot Framework Qase library, which is not suitabl
 		Run:              RunCommand,
 	}
 
-	qaseClient  qase.APIClient   // Qase API client
-	testResults []TestCaseResult // Stores the case result from XML and pass to Qase
-	xmlDoc      *etree.Document  // Stores the XML document
+	qaseClient   qase.APIClient   // Qase API client
+	resultParser ResultParser     // Parses the input file into TestCaseResult
+	testResults  []TestCaseResult // Stores the case result from XML and pass to Qase
 )
 
 func init() {
 	cobra.OnInitialize()
 
-	cmd.Flags().StringP("project", "p", "", "Qase project name")
-	cmd.Flags().StringP("api_token", "t", "", "Qase API token")
-	cmd.Flags().StringP("run_title", "r", "", "Qase run title")
-
-	viper.BindPFlag("project", cmd.Flags().Lookup("project"))
-	viper.BindPFlag("api_token", cmd.Flags().Lookup("api-token"))
-	viper.BindPFlag("run_title", cmd.Flags().Lookup("run-title"))
+	// Persistent: shared with the `serve` subcommand, which also talks to Qase.
+	cmd.PersistentFlags().StringP("project", "p", "", "Qase project name")
+	cmd.PersistentFlags().StringP("api_token", "t", "", "Qase API token")
+	cmd.PersistentFlags().StringP("run_title", "r", "", "Qase run title")
+	cmd.PersistentFlags().Int32("run_id", 0, "Existing Qase run ID to append results to, instead of creating a new run")
+	cmd.PersistentFlags().Bool("reuse_run", false, "Reuse an open run whose title matches run_title instead of creating a new one")
+	cmd.PersistentFlags().Bool("complete", true, "Complete the run after reporting results")
+	cmd.PersistentFlags().Int("batch_size", DEFAULT_BATCH_SIZE, "Number of results to submit per CreateResultBulk call")
+	cmd.PersistentFlags().Int("concurrency", 1, "Number of result batches to submit concurrently")
+	cmd.PersistentFlags().Bool("dry_run", false, "Log the result payloads instead of submitting them to Qase")
+
+	// Local: only meaningful when parsing a single output file.
+	cmd.Flags().StringP("format", "f", FORMAT_AUTO, "Input format: auto, robot, or junit")
+	cmd.Flags().Bool("auto_create", false, "Create a Qase case for tests without a Q-<id> tag")
+	cmd.Flags().String("mapping_file", "qase-mapping.json", "Path to the test-name-to-case-ID mapping file used by --auto-create")
+
+	viper.BindPFlag("project", cmd.PersistentFlags().Lookup("project"))
+	viper.BindPFlag("api_token", cmd.PersistentFlags().Lookup("api_token"))
+	viper.BindPFlag("run_title", cmd.PersistentFlags().Lookup("run_title"))
+	viper.BindPFlag("run_id", cmd.PersistentFlags().Lookup("run_id"))
+	viper.BindPFlag("reuse_run", cmd.PersistentFlags().Lookup("reuse_run"))
+	viper.BindPFlag("complete", cmd.PersistentFlags().Lookup("complete"))
+	viper.BindPFlag("batch_size", cmd.PersistentFlags().Lookup("batch_size"))
+	viper.BindPFlag("concurrency", cmd.PersistentFlags().Lookup("concurrency"))
+	viper.BindPFlag("dry_run", cmd.PersistentFlags().Lookup("dry_run"))
+	viper.BindPFlag("format", cmd.Flags().Lookup("format"))
+	viper.BindPFlag("auto_create", cmd.Flags().Lookup("auto_create"))
+	viper.BindPFlag("mapping_file", cmd.Flags().Lookup("mapping_file"))
 
 	// Adopts the official Qase environment variables
 	viper.BindEnv("project", "QASE_TESTOPS_PROJECT")
 	viper.BindEnv("api_token", "QASE_TESTOPS_API_TOKEN")
 	viper.BindEnv("run_title", "QASE_TESTOPS_RUN_TITLE")
+
+	cmd.AddCommand(serveCmd)
 }
 
 func main() {
@@ -95,11 +140,21 @@ func preRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatalf("Unable to read Viper options into configuration: %v", err)
 	}
-	config.Filename = args[0]
 
 	//log.Printf("Config: %+v", config)
 	ctx = context.Background()
 
+	// `serve` has no input file to parse; it builds results from incoming
+	// XML-RPC calls instead.
+	if len(args) > 0 {
+		config.Filename = args[0]
+
+		resultParser, err = NewResultParser(config.Format, config.Filename)
+		if err != nil {
+			log.Fatalf("Unable to determine result parser: %v", err)
+		}
+	}
+
 	initQaseClient()
 }
 
@@ -112,13 +167,16 @@ func initQaseClient() {
 func RunCommand(cmd *cobra.Command, args []string) {
 	var err error
 	fmt.Println("Running qase-robotframework-reporter")
-	if err = readXmlFile(); err != nil {
-		log.Fatalf("Error reading file: %v", err)
+
+	// Parse the input file using the parser selected in preRun
+	if testResults, err = parseTestResultsFromFile(); err != nil {
+		log.Fatalf("Error parsing input file: %v", err)
 	}
 
-	// Parse XML
-	if err = parseTestResultsFromXml(); err != nil {
-		log.Fatalf("Error parsing XML: %v", err)
+	if config.AutoCreate {
+		if testResults, err = resolveAutoCreatedCases(testResults); err != nil {
+			log.Fatalf("Error auto-creating Qase cases: %v", err)
+		}
 	}
 
 	// Report to Qase
@@ -127,48 +185,37 @@ func RunCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
-func readXmlFile() (err error) {
+func parseTestResultsFromFile() (results []TestCaseResult, err error) {
 	// Print absolute path
-	if filename, err := filepath.Abs(config.Filename); err == nil {
+	if filename, aErr := filepath.Abs(config.Filename); aErr == nil {
 		log.Println("Reading file: ", filename)
 	}
 
-	// Openfile
-	xmlDoc = etree.NewDocument()
-	if err = xmlDoc.ReadFromFile(config.Filename); err != nil {
-		err = fmt.Errorf("error reading XML file: %v", err)
+	file, err := os.Open(config.Filename)
+	if err != nil {
+		err = fmt.Errorf("error opening input file: %v", err)
 		return
 	}
+	defer file.Close()
 
-	return
-}
-
-func parseTestResultsFromXml() (err error) {
-	root := xmlDoc.Root()
-	if root == nil || root.Tag != "robot" {
-		err = fmt.Errorf("cannot find robot root node")
+	results, err = resultParser.Parse(file)
+	if err != nil {
+		err = fmt.Errorf("error parsing input file: %v", err)
 		return
 	}
 
-	testResults = make([]TestCaseResult, 0)
-	for _, childElmt := range root.FindElements("//test") {
-		//fmt.Println(childElmt.Tag)
-
-		testResult, pErr := parseTestResultFromTestXmlElement(childElmt)
-		if pErr != nil {
-			log.Printf("Error parsing test result: %v", pErr)
-			continue
-		}
-		testResults = append(testResults, testResult)
-	}
-
 	return
 }
 
 func reportToQase() (err error) {
-	runId, err := createNewQaseRun()
+	if config.DryRun {
+		log.Printf("[dry-run] Skipping run creation/completion and attachment uploads")
+		return createQaseTestRunResults(0)
+	}
+
+	runId, err := resolveQaseRun(false)
 	if err != nil {
-		log.Fatalf("Failed to create test run: %v", err)
+		log.Fatalf("Failed to resolve test run: %v", err)
 	}
 
 	err = createQaseTestRunResults(runId)
@@ -176,6 +223,11 @@ func reportToQase() (err error) {
 		log.Fatalf("Failed to create test run result: %v", err)
 	}
 
+	if !config.Complete {
+		log.Printf("Skipping run completion (--complete=false)")
+		return
+	}
+
 	err = completeQaseRun(runId)
 	if err != nil {
 		log.Fatalf("Failed to complete test run: %v", err)
@@ -183,13 +235,19 @@ func reportToQase() (err error) {
 	return
 }
 
-func parseTestResultFromTestXmlElement(element *etree.Element) (result TestCaseResult, err error) {
+func parseTestResultFromTestXmlElement(element *etree.Element, baseDir string) (result TestCaseResult, err error) {
 	// assume we have 1 tag for now
 
 	result.TestCaseId, err = parseQaseIdFromTestElement(element)
 	if err != nil {
-		err = fmt.Errorf("error parsing Qase ID: %v", err)
-		return
+		if !config.AutoCreate {
+			err = fmt.Errorf("error parsing Qase ID: %v", err)
+			return
+		}
+		// No Q-<id> tag: carry enough metadata for --auto-create to create
+		// the case later, instead of dropping the test.
+		result.TestName, result.Doc, result.Tags, result.SuiteName = parseAutoCreateMetadataFromTestElement(element)
+		err = nil
 	}
 
 	result.Status,
@@ -203,6 +261,8 @@ func parseTestResultFromTestXmlElement(element *etree.Element) (result TestCaseR
 		return
 	}
 
+	result.Steps, result.Comment, result.Stacktrace, result.Attachments = collectStepsFromTestElement(element, baseDir)
+
 	return
 }
 
@@ -232,6 +292,34 @@ func parseQaseIdFromTestElement(element *etree.Element) (qaseId int64, err error
 	return
 }
 
+// parseAutoCreateMetadataFromTestElement gathers what --auto-create needs to
+// create a matching Qase case: the test's own name, its <doc> text, its
+// non-Q-<id> tags, and the dotted suite path built from its ancestor <suite>
+// elements.
+func parseAutoCreateMetadataFromTestElement(element *etree.Element) (name string, doc string, tags []string, suiteName string) {
+	name = element.SelectAttrValue("name", "")
+
+	if docElmt := element.FindElement("doc"); docElmt != nil {
+		doc = docElmt.Text()
+	}
+
+	regex := regexp.MustCompile(`Q-(\d+)`)
+	for _, tag := range element.SelectElements("tag") {
+		text := tag.Text()
+		if !regex.MatchString(text) {
+			tags = append(tags, text)
+		}
+	}
+
+	var suitePath []string
+	for parent := element.Parent(); parent != nil && parent.Tag == "suite"; parent = parent.Parent() {
+		suitePath = append([]string{parent.SelectAttrValue("name", "")}, suitePath...)
+	}
+	suiteName = strings.Join(suitePath, " > ")
+
+	return
+}
+
 func parseStatusAndTimeFromTestElement(element *etree.Element) (status string, startTime time.Time, timeMs int64, err error) {
 	statusTag := element.FindElement("status")
 	if statusTag == nil {
@@ -336,98 +424,3 @@ func parseTimeFromTestStatusElement(element *etree.Element, startTime time.Time,
 	timeMs = int64(endTime.Sub(startTime).Milliseconds())
 	return
 }
-
-func createNewQaseRun() (runId int32, err error) {
-	// Create Test Run
-	log.Printf("Creating test run")
-	caseIds := make([]int64, 0)
-	for _, result := range testResults {
-		caseIds = append(caseIds, result.TestCaseId)
-	}
-
-	qaseResp, httpResp, err := qaseClient.RunsApi.CreateRun(ctx, qase.RunCreate{
-		Title: config.QaseRunTitle,
-		Cases: caseIds,
-	}, config.QaseProject)
-	if err != nil {
-		err = fmt.Errorf("failed to create test run: %v", err)
-		return
-	}
-
-	if httpResp.StatusCode != 200 {
-		err = fmt.Errorf("failed to create test run, status code: %v", httpResp.StatusCode)
-		return
-	}
-
-	runId = int32(qaseResp.Result.Id)
-	log.Printf("Created test run ID: %d", runId)
-	return
-}
-
-func createQaseTestRunResults(runId int32) (err error) {
-	log.Printf("Creating test run results for run ID: %d", runId)
-	qaseResults := make([]qase.ResultCreate, 0)
-	for _, result := range testResults {
-		qaseResult := qase.ResultCreate{
-			CaseId: int64(result.TestCaseId),
-			Status: result.Status,
-			// Somewhat this result in bad request
-			//Time:   result.Time.Unix(),
-			TimeMs: result.TimeMs,
-		}
-		if result.Package != "" {
-			qaseResult.Comment = fmt.Sprintf("Package: %v", result.Package)
-		}
-		qaseResults = append(qaseResults, qaseResult)
-	}
-
-	qaseResp, httpResp, err := qaseClient.ResultsApi.CreateResultBulk(ctx, qase.ResultCreateBulk{
-		Results: qaseResults,
-	}, config.QaseProject, runId)
-
-	if err != nil {
-		// read body to string
-		message, _ := io.ReadAll(httpResp.Body)
-		err = fmt.Errorf("failed to create test run results: %v %s", err, message)
-		return
-	}
-
-	if httpResp.StatusCode != 200 {
-		message, _ := io.ReadAll(httpResp.Body)
-		err = fmt.Errorf("failed to create test run results, status code: %v %s", httpResp.StatusCode, message)
-		return
-	}
-
-	if !qaseResp.Status {
-		err = fmt.Errorf("failed to create test run results, status false")
-		return
-	}
-
-	return
-}
-
-func completeQaseRun(runId int32) (err error) {
-	// Complete Test Run
-	log.Printf("Completing test run ID: %d", runId)
-	qaseResp, httpResp, err := qaseClient.RunsApi.CompleteRun(
-		ctx,
-		config.QaseProject,
-		runId,
-	)
-	if err != nil {
-		err = fmt.Errorf("failed to complete test run: %v", err)
-		return
-	}
-
-	if httpResp.StatusCode != 200 {
-		err = fmt.Errorf("failed to complete test run, status code: %v", httpResp.StatusCode)
-		return
-	}
-
-	if !qaseResp.Status {
-		err = fmt.Errorf("failed to complete test run, status false")
-		return
-	}
-	log.Printf("Completed test run ID: %d", runId)
-	return nil
-}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	FORMAT_AUTO  = "auto"
+	FORMAT_ROBOT = "robot"
+	FORMAT_JUNIT = "junit"
+)
+
+// qaseIdRegex matches the `Q-<id>` tag convention used to link a test case
+// to its Qase counterpart, regardless of which parser extracted the text.
+var qaseIdRegex = regexp.MustCompile(`Q-(\d+)`)
+
+// ResultParser turns a test report document into the reporter's internal
+// TestCaseResult representation. Implementations are selected in preRun
+// based on the --format flag or by sniffing the input file.
+type ResultParser interface {
+	Parse(r io.Reader) ([]TestCaseResult, error)
+}
+
+// NewResultParser returns the ResultParser matching format. If format is
+// FORMAT_AUTO (or empty), the format is detected from filename and, failing
+// that, from the root element of the document itself.
+func NewResultParser(format string, filename string) (parser ResultParser, err error) {
+	if format == "" {
+		format = FORMAT_AUTO
+	}
+
+	if format == FORMAT_AUTO {
+		format, err = detectFormat(filename)
+		if err != nil {
+			return
+		}
+	}
+
+	switch format {
+	case FORMAT_ROBOT:
+		parser = &RobotFrameworkParser{BaseDir: filepath.Dir(filename)}
+	case FORMAT_JUNIT:
+		parser = &JUnitParser{}
+	default:
+		err = fmt.Errorf("unknown format: %s", format)
+	}
+	return
+}
+
+// detectFormat guesses the report format from the filename, falling back to
+// inspecting the document's root element when the extension is ambiguous.
+func detectFormat(filename string) (format string, err error) {
+	base := strings.ToLower(filepath.Base(filename))
+	switch {
+	case strings.Contains(base, "junit"):
+		return FORMAT_JUNIT, nil
+	case strings.Contains(base, "output"):
+		return FORMAT_ROBOT, nil
+	}
+
+	doc := etree.NewDocument()
+	if err = doc.ReadFromFile(filename); err != nil {
+		err = fmt.Errorf("error reading XML file to detect format: %v", err)
+		return
+	}
+
+	root := doc.Root()
+	if root == nil {
+		err = fmt.Errorf("cannot detect format: empty document")
+		return
+	}
+
+	switch root.Tag {
+	case "robot":
+		format = FORMAT_ROBOT
+	case "testsuites", "testsuite":
+		format = FORMAT_JUNIT
+	default:
+		err = fmt.Errorf("cannot detect format from root element %q", root.Tag)
+	}
+	return
+}
+
+// extractQaseId looks for the `Q-<id>` convention inside text and returns the
+// parsed ID. ok is false when no match is found.
+func extractQaseId(text string) (qaseId int64, ok bool) {
+	if !qaseIdRegex.MatchString(text) {
+		return
+	}
+	qaseIdText := qaseIdRegex.FindStringSubmatch(text)[1]
+	id, err := strconv.ParseInt(qaseIdText, 10, 64)
+	if err != nil {
+		return
+	}
+	return id, true
+}
+
+// RobotFrameworkParser parses Robot Framework's output.xml format
+// (`<robot><suite><test>...`). BaseDir is the directory containing
+// output.xml, used to resolve screenshot/log paths found in keyword
+// messages.
+type RobotFrameworkParser struct {
+	BaseDir string
+}
+
+func (p *RobotFrameworkParser) Parse(r io.Reader) (results []TestCaseResult, err error) {
+	doc := etree.NewDocument()
+	if _, err = doc.ReadFrom(r); err != nil {
+		err = fmt.Errorf("error reading XML: %v", err)
+		return
+	}
+
+	root := doc.Root()
+	if root == nil || root.Tag != "robot" {
+		err = fmt.Errorf("cannot find robot root node")
+		return
+	}
+
+	results = make([]TestCaseResult, 0)
+	for _, childElmt := range root.FindElements("//test") {
+		result, pErr := parseTestResultFromTestXmlElement(childElmt, p.BaseDir)
+		if pErr != nil {
+			log.Printf("Error parsing test result: %v", pErr)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return
+}
+
+// JUnitParser parses the JUnit XML format commonly emitted by pytest, Qt
+// qtestlib, `go test -json` convertors, and most CI test runners
+// (`<testsuites><testsuite><testcase>...`).
+type JUnitParser struct{}
+
+func (p *JUnitParser) Parse(r io.Reader) (results []TestCaseResult, err error) {
+	doc := etree.NewDocument()
+	if _, err = doc.ReadFrom(r); err != nil {
+		err = fmt.Errorf("error reading XML: %v", err)
+		return
+	}
+
+	root := doc.Root()
+	if root == nil || (root.Tag != "testsuites" && root.Tag != "testsuite") {
+		err = fmt.Errorf("cannot find testsuites/testsuite root node")
+		return
+	}
+
+	results = make([]TestCaseResult, 0)
+	for _, testcase := range root.FindElements("//testcase") {
+		result, pErr := parseTestResultFromJUnitTestCaseElement(testcase)
+		if pErr != nil {
+			log.Printf("Error parsing test result: %v", pErr)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return
+}
+
+func parseTestResultFromJUnitTestCaseElement(element *etree.Element) (result TestCaseResult, err error) {
+	result.TestCaseId, err = parseQaseIdFromJUnitTestCaseElement(element)
+	if err != nil {
+		err = fmt.Errorf("error parsing Qase ID: %v", err)
+		return
+	}
+
+	result.Status = parseStatusFromJUnitTestCaseElement(element)
+
+	result.Package = element.SelectAttrValue("classname", "")
+
+	timeText := element.SelectAttrValue("time", "")
+	if timeText != "" {
+		var timeSeconds float64
+		timeSeconds, err = strconv.ParseFloat(timeText, 64)
+		if err != nil {
+			err = fmt.Errorf("error parsing time attribute: %v", err)
+			return
+		}
+		result.TimeMs = int64(timeSeconds * 1000)
+	}
+
+	log.Printf("Test case ID: %d, Status: %s, TimeMs: %d", result.TestCaseId, result.Status, result.TimeMs)
+	return
+}
+
+// parseQaseIdFromJUnitTestCaseElement looks for the Qase ID in a
+// `<properties><property name="..." value="Q-<id>"/></properties>` block,
+// falling back to the test case's own name since JUnit has no native
+// concept of tags.
+func parseQaseIdFromJUnitTestCaseElement(element *etree.Element) (qaseId int64, err error) {
+	for _, property := range element.FindElements("properties/property") {
+		value := property.SelectAttrValue("value", "")
+		if id, ok := extractQaseId(value); ok {
+			return id, nil
+		}
+	}
+
+	name := element.SelectAttrValue("name", "")
+	if id, ok := extractQaseId(name); ok {
+		return id, nil
+	}
+
+	err = fmt.Errorf("cannot find Qase ID in testcase properties or name")
+	return
+}
+
+func parseStatusFromJUnitTestCaseElement(element *etree.Element) string {
+	if element.FindElement("failure") != nil || element.FindElement("error") != nil {
+		return TEST_RESULT_STATUS_FAILED
+	}
+	if element.FindElement("skipped") != nil {
+		return TEST_RESULT_STATUS_SKIPPED
+	}
+	return TEST_RESULT_STATUS_PASSED
+}
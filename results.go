@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	qase "go.qase.io/client"
+)
+
+const (
+	// DEFAULT_BATCH_SIZE is how many results are submitted per
+	// CreateResultBulk call when --batch-size is not set.
+	DEFAULT_BATCH_SIZE = 200
+
+	MAX_RESULT_SUBMIT_RETRIES = 5
+	RESULT_SUBMIT_BACKOFF     = 2 * time.Second
+)
+
+// createQaseTestRunResults builds the Qase payload for every parsed test
+// result, splits it into batches, and submits them - concurrently, up to
+// --concurrency at a time - with retry/backoff. Failures are aggregated
+// across batches instead of aborting on the first one.
+func createQaseTestRunResults(runId int32) (err error) {
+	if config.DryRun {
+		log.Printf("[dry-run] Building test run result payloads")
+	} else {
+		log.Printf("Creating test run results for run ID: %d", runId)
+	}
+
+	// Attachments are only uploaded when actually submitting: --dry-run must
+	// not call the Attachments API either.
+	qaseResults := buildQaseResults(testResults, !config.DryRun)
+
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DEFAULT_BATCH_SIZE
+	}
+	batches := splitResultsIntoBatches(qaseResults, batchSize)
+
+	if config.DryRun {
+		return logDryRunBatches(batches)
+	}
+
+	return submitResultBatches(runId, batches)
+}
+
+// buildQaseResults converts the parsed test results into the Qase API's
+// payload shape. When uploadAttachments is false (--dry-run), attachment
+// file paths are logged as-is instead of being uploaded for a hash.
+func buildQaseResults(testResults []TestCaseResult, uploadAttachments bool) []qase.ResultCreate {
+	qaseResults := make([]qase.ResultCreate, 0, len(testResults))
+	for _, result := range testResults {
+		qaseResult := qase.ResultCreate{
+			CaseId: int64(result.TestCaseId),
+			Status: result.Status,
+			// Somewhat this result in bad request
+			//Time:   result.Time.Unix(),
+			TimeMs:     result.TimeMs,
+			Stacktrace: result.Stacktrace,
+		}
+
+		comment := result.Comment
+		if result.Package != "" {
+			comment = fmt.Sprintf("Package: %v\n\n%s", result.Package, comment)
+		}
+		qaseResult.Comment = comment
+
+		if uploadAttachments {
+			hashesByPath := uploadAttachmentSet(result.Attachments)
+			qaseResult.Attachments = attachmentHashesFor(result.Attachments, hashesByPath)
+			qaseResult.Steps = buildQaseResultSteps(result.Steps, hashesByPath)
+		} else {
+			qaseResult.Attachments = result.Attachments
+			qaseResult.Steps = buildQaseResultSteps(result.Steps, nil)
+		}
+
+		qaseResults = append(qaseResults, qaseResult)
+	}
+	return qaseResults
+}
+
+func splitResultsIntoBatches(results []qase.ResultCreate, batchSize int) [][]qase.ResultCreate {
+	if len(results) == 0 {
+		return nil
+	}
+
+	batches := make([][]qase.ResultCreate, 0, (len(results)+batchSize-1)/batchSize)
+	for start := 0; start < len(results); start += batchSize {
+		end := start + batchSize
+		if end > len(results) {
+			end = len(results)
+		}
+		batches = append(batches, results[start:end])
+	}
+	return batches
+}
+
+func logDryRunBatches(batches [][]qase.ResultCreate) error {
+	for i, batch := range batches {
+		payload, err := json.Marshal(qase.ResultCreateBulk{Results: batch})
+		if err != nil {
+			return fmt.Errorf("error marshalling dry-run batch %d: %v", i+1, err)
+		}
+		log.Printf("[dry-run] batch %d/%d payload: %s", i+1, len(batches), payload)
+	}
+	return nil
+}
+
+// submitResultBatches fans the batches out through a worker pool bounded by
+// config.Concurrency and aggregates every batch's error, if any, into a
+// single error report instead of failing on the first problem.
+func submitResultBatches(runId int32, batches [][]qase.ResultCreate) error {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var batchErrors []error
+
+	for i, batch := range batches {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, batch []qase.ResultCreate) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if err := submitResultBatchWithRetry(runId, i, batch); err != nil {
+				mu.Lock()
+				batchErrors = append(batchErrors, fmt.Errorf("batch %d: %v", i+1, err))
+				mu.Unlock()
+			}
+		}(i, batch)
+	}
+	wg.Wait()
+
+	if len(batchErrors) > 0 {
+		return fmt.Errorf("failed to submit %d/%d result batches: %w", len(batchErrors), len(batches), joinErrors(batchErrors))
+	}
+	return nil
+}
+
+func joinErrors(errs []error) error {
+	msg := ""
+	for i, err := range errs {
+		if i > 0 {
+			msg += "; "
+		}
+		msg += err.Error()
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// submitResultBatchWithRetry submits a single batch, retrying on HTTP 429
+// (honoring Retry-After) and on 5xx/network errors with exponential
+// backoff, up to MAX_RESULT_SUBMIT_RETRIES attempts.
+func submitResultBatchWithRetry(runId int32, batchIndex int, batch []qase.ResultCreate) (err error) {
+	backoff := RESULT_SUBMIT_BACKOFF
+
+	for attempt := 1; attempt <= MAX_RESULT_SUBMIT_RETRIES; attempt++ {
+		var httpResp *http.Response
+		var qaseResp qase.Response
+		qaseResp, httpResp, err = qaseClient.ResultsApi.CreateResultBulk(ctx, qase.ResultCreateBulk{
+			Results: batch,
+		}, config.QaseProject, runId)
+
+		if err == nil && httpResp.StatusCode == 200 && qaseResp.Status {
+			return nil
+		}
+
+		retryable, wait := shouldRetryResultSubmit(httpResp, err, backoff)
+		if !retryable || attempt == MAX_RESULT_SUBMIT_RETRIES {
+			return describeResultSubmitError(httpResp, qaseResp, err)
+		}
+
+		log.Printf("Batch %d: retrying in %v after attempt %d/%d: %v", batchIndex+1, wait, attempt, MAX_RESULT_SUBMIT_RETRIES, describeResultSubmitError(httpResp, qaseResp, err))
+		time.Sleep(wait)
+		backoff *= 2
+	}
+
+	return
+}
+
+func shouldRetryResultSubmit(httpResp *http.Response, err error, backoff time.Duration) (retryable bool, wait time.Duration) {
+	if httpResp == nil {
+		// Network-level error: assume transient and retry.
+		return true, backoff
+	}
+
+	if httpResp.StatusCode == http.StatusTooManyRequests {
+		if retryAfter := parseRetryAfter(httpResp.Header.Get("Retry-After")); retryAfter > 0 {
+			return true, retryAfter
+		}
+		return true, backoff
+	}
+
+	if httpResp.StatusCode >= 500 {
+		return true, backoff
+	}
+
+	return false, 0
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+func describeResultSubmitError(httpResp *http.Response, qaseResp qase.Response, err error) error {
+	if err != nil {
+		if httpResp != nil && httpResp.Body != nil {
+			message, _ := io.ReadAll(httpResp.Body)
+			return fmt.Errorf("failed to create test run results: %v %s", err, message)
+		}
+		return fmt.Errorf("failed to create test run results: %v", err)
+	}
+
+	if httpResp.StatusCode != 200 {
+		message, _ := io.ReadAll(httpResp.Body)
+		return fmt.Errorf("failed to create test run results, status code: %v %s", httpResp.StatusCode, message)
+	}
+
+	if !qaseResp.Status {
+		return fmt.Errorf("failed to create test run results, status false")
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/antihax/optional"
+	qase "go.qase.io/client"
+)
+
+// RUN_STATUS_FILTER_ACTIVE is the Qase run status filter value for runs
+// that are still open, used when looking for a run to reuse.
+const RUN_STATUS_FILTER_ACTIVE = "active"
+
+// resolveQaseRun decides which Qase run the results should be reported
+// against: an explicit --run-id, an existing open run matching run_title
+// when --reuse-run is set, or a newly created run otherwise. includeAllCases
+// is passed through to createNewQaseRun for callers (the serve listener)
+// that don't know their case IDs up front.
+func resolveQaseRun(includeAllCases bool) (runId int32, err error) {
+	if config.RunId != 0 {
+		log.Printf("Using existing run ID: %d", config.RunId)
+		return config.RunId, nil
+	}
+
+	if config.ReuseRun {
+		var found bool
+		runId, found, err = findOpenQaseRunByTitle(config.QaseRunTitle)
+		if err != nil {
+			return
+		}
+		if found {
+			log.Printf("Reusing existing run ID: %d", runId)
+			return
+		}
+		log.Printf("No open run found matching title %q, creating a new one", config.QaseRunTitle)
+	}
+
+	return createNewQaseRun(includeAllCases)
+}
+
+// findOpenQaseRunByTitle searches the project's active runs for one whose
+// title matches title exactly, for use with --reuse-run.
+func findOpenQaseRunByTitle(title string) (runId int32, found bool, err error) {
+	qaseResp, httpResp, err := qaseClient.RunsApi.GetRuns(ctx, config.QaseProject, &qase.RunsApiGetRunsOpts{
+		FiltersStatus: optional.NewString(RUN_STATUS_FILTER_ACTIVE),
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to list test runs: %v", err)
+		return
+	}
+
+	if httpResp.StatusCode != 200 {
+		err = fmt.Errorf("failed to list test runs, status code: %v", httpResp.StatusCode)
+		return
+	}
+
+	for _, run := range qaseResp.Result.Entities {
+		if run.Title == title {
+			return int32(run.Id), true, nil
+		}
+	}
+
+	return
+}
+
+// createNewQaseRun creates a run scoped to the parsed file's case IDs, or,
+// when includeAllCases is set (the serve listener, which has no case list
+// up front since tests haven't run yet), a run that accepts any case ID
+// reported against it.
+func createNewQaseRun(includeAllCases bool) (runId int32, err error) {
+	// Create Test Run
+	log.Printf("Creating test run")
+
+	runCreate := qase.RunCreate{Title: config.QaseRunTitle}
+	if includeAllCases {
+		runCreate.IncludeAllCases = true
+	} else {
+		caseIds := make([]int64, 0, len(testResults))
+		for _, result := range testResults {
+			caseIds = append(caseIds, result.TestCaseId)
+		}
+		runCreate.Cases = caseIds
+	}
+
+	qaseResp, httpResp, err := qaseClient.RunsApi.CreateRun(ctx, runCreate, config.QaseProject)
+	if err != nil {
+		err = fmt.Errorf("failed to create test run: %v", err)
+		return
+	}
+
+	if httpResp.StatusCode != 200 {
+		err = fmt.Errorf("failed to create test run, status code: %v", httpResp.StatusCode)
+		return
+	}
+
+	runId = int32(qaseResp.Result.Id)
+	log.Printf("Created test run ID: %d", runId)
+	return
+}
+
+func completeQaseRun(runId int32) (err error) {
+	// Complete Test Run
+	log.Printf("Completing test run ID: %d", runId)
+	qaseResp, httpResp, err := qaseClient.RunsApi.CompleteRun(
+		ctx,
+		config.QaseProject,
+		runId,
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to complete test run: %v", err)
+		return
+	}
+
+	if httpResp.StatusCode != 200 {
+		err = fmt.Errorf("failed to complete test run, status code: %v", httpResp.StatusCode)
+		return
+	}
+
+	if !qaseResp.Status {
+		err = fmt.Errorf("failed to complete test run, status false")
+		return
+	}
+	log.Printf("Completed test run ID: %d", runId)
+	return nil
+}
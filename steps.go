@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// StepResult is the keyword-level detail collected from a single Robot
+// Framework <kw> element, suitable for reporting to Qase as a result step.
+type StepResult struct {
+	Name        string
+	Status      string
+	Messages    []string
+	Attachments []string // absolute paths of files referenced from log messages
+}
+
+// htmlLinkRegex extracts `href`/`src` attribute values from the HTML bodies
+// Robot Framework embeds in `<msg html="true">` elements to link
+// screenshots and other log files.
+var htmlLinkRegex = regexp.MustCompile(`(?:href|src)="([^"]+)"`)
+
+// collectStepsFromTestElement walks the <kw> tree under a <test> element and
+// builds the step list, an aggregated Markdown comment, the stacktrace of any
+// failure, and the set of attachment files referenced along the way. baseDir
+// is the directory the log/screenshot paths found in messages are resolved
+// against (the directory containing output.xml).
+func collectStepsFromTestElement(test *etree.Element, baseDir string) (steps []StepResult, comment string, stacktrace string, attachments []string) {
+	seen := make(map[string]bool)
+	position := int32(0)
+
+	var walk func(kw *etree.Element)
+	walk = func(kw *etree.Element) {
+		position++
+		step := StepResult{
+			Name:   kw.SelectAttrValue("name", ""),
+			Status: TEST_RESULT_STATUS_PASSED,
+		}
+		if statusTag := kw.FindElement("status"); statusTag != nil {
+			switch statusTag.SelectAttrValue("status", "") {
+			case "PASS":
+				// step.Status already defaults to passed.
+			case "SKIP":
+				step.Status = TEST_RESULT_STATUS_SKIPPED
+			default:
+				step.Status = TEST_RESULT_STATUS_FAILED
+			}
+		}
+
+		for _, msg := range kw.SelectElements("msg") {
+			text := msg.Text()
+			step.Messages = append(step.Messages, text)
+
+			if msg.SelectAttrValue("level", "") == "FAIL" {
+				if stacktrace != "" {
+					stacktrace += "\n"
+				}
+				stacktrace += fmt.Sprintf("[%s] %s", step.Name, text)
+			}
+
+			if msg.SelectAttrValue("html", "") == "true" {
+				for _, match := range htmlLinkRegex.FindAllStringSubmatch(text, -1) {
+					path := resolveAttachmentPath(baseDir, match[1])
+					if path == "" || seen[path] {
+						continue
+					}
+					seen[path] = true
+					step.Attachments = append(step.Attachments, path)
+					attachments = append(attachments, path)
+				}
+			}
+		}
+
+		steps = append(steps, step)
+
+		for _, child := range kw.SelectElements("kw") {
+			walk(child)
+		}
+	}
+
+	for _, kw := range test.SelectElements("kw") {
+		walk(kw)
+	}
+
+	comment = renderStepsMarkdown(steps)
+	return
+}
+
+// resolveAttachmentPath resolves an href/src found in a Robot Framework log
+// message relative to baseDir. Remote URLs (http/https) are left alone, as
+// is the empty string.
+func resolveAttachmentPath(baseDir string, ref string) string {
+	if ref == "" || strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		return ""
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(baseDir, ref)
+}
+
+// renderStepsMarkdown builds a Markdown summary of the steps, used as the
+// Qase result Comment when no higher-level comment is configured.
+func renderStepsMarkdown(steps []StepResult) string {
+	if len(steps) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, step := range steps {
+		marker := "✅"
+		if step.Status == TEST_RESULT_STATUS_FAILED {
+			marker = "❌"
+		}
+		fmt.Fprintf(&b, "%d. %s **%s**\n", i+1, marker, step.Name)
+		for _, msg := range step.Messages {
+			fmt.Fprintf(&b, "   - %s\n", msg)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// This file implements just enough of the XML-RPC wire format (the
+// methodCall/methodResponse subset used by Robot Framework's remote
+// listener interface) to avoid pulling in a full XML-RPC dependency for
+// what is effectively one-way notifications.
+
+type xmlRpcValue struct {
+	String   *string       `xml:"string"`
+	Int      *int64        `xml:"int"`
+	I4       *int64        `xml:"i4"`
+	Boolean  *string       `xml:"boolean"`
+	Double   *float64      `xml:"double"`
+	Array    *xmlRpcArray  `xml:"array"`
+	Struct   *xmlRpcStruct `xml:"struct"`
+	Nil      *struct{}     `xml:"nil"`
+	CharData string        `xml:",chardata"`
+}
+
+type xmlRpcArray struct {
+	Data struct {
+		Value []xmlRpcValue `xml:"value"`
+	} `xml:"data"`
+}
+
+type xmlRpcStruct struct {
+	Member []xmlRpcMember `xml:"member"`
+}
+
+type xmlRpcMember struct {
+	Name  string      `xml:"name"`
+	Value xmlRpcValue `xml:"value"`
+}
+
+type xmlRpcMethodCall struct {
+	XMLName    xml.Name `xml:"methodCall"`
+	MethodName string   `xml:"methodName"`
+	Params     struct {
+		Param []struct {
+			Value xmlRpcValue `xml:"value"`
+		} `xml:"param"`
+	} `xml:"params"`
+}
+
+// toGo converts a decoded <value> element into a plain Go value: string,
+// int64, bool, float64, []interface{}, map[string]interface{}, or nil.
+func (v xmlRpcValue) toGo() interface{} {
+	switch {
+	case v.Nil != nil:
+		return nil
+	case v.String != nil:
+		return *v.String
+	case v.Int != nil:
+		return *v.Int
+	case v.I4 != nil:
+		return *v.I4
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1"
+	case v.Double != nil:
+		return *v.Double
+	case v.Array != nil:
+		arr := make([]interface{}, 0, len(v.Array.Data.Value))
+		for _, item := range v.Array.Data.Value {
+			arr = append(arr, item.toGo())
+		}
+		return arr
+	case v.Struct != nil:
+		m := make(map[string]interface{}, len(v.Struct.Member))
+		for _, member := range v.Struct.Member {
+			m[member.Name] = member.Value.toGo()
+		}
+		return m
+	default:
+		// A bare <value>some text</value> with no type element is a string.
+		return strings.TrimSpace(v.CharData)
+	}
+}
+
+// decodeXmlRpcMethodCall parses an XML-RPC <methodCall> request body into
+// its method name and positional parameters.
+func decodeXmlRpcMethodCall(r io.Reader) (method string, params []interface{}, err error) {
+	var call xmlRpcMethodCall
+	if err = xml.NewDecoder(r).Decode(&call); err != nil {
+		err = fmt.Errorf("error decoding XML-RPC method call: %v", err)
+		return
+	}
+
+	method = call.MethodName
+	for _, param := range call.Params.Param {
+		params = append(params, param.Value.toGo())
+	}
+	return
+}
+
+// writeXmlRpcResponse writes an XML-RPC <methodResponse>, as a single string
+// result or as a <fault> when handlerErr is non-nil. Remote listener callers
+// only care whether the call succeeded, so the result is always a string.
+func writeXmlRpcResponse(w http.ResponseWriter, result string, handlerErr error) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+
+	if handlerErr != nil {
+		fmt.Fprintf(w, `<?xml version="1.0"?>`+
+			`<methodResponse><fault><value><struct>`+
+			`<member><name>faultCode</name><value><int>1</int></value></member>`+
+			`<member><name>faultString</name><value><string>%s</string></value></member>`+
+			`</struct></value></fault></methodResponse>`, xmlEscape(handlerErr.Error()))
+		return
+	}
+
+	fmt.Fprintf(w, `<?xml version="1.0"?>`+
+		`<methodResponse><params><param><value><string>%s</string></value></param></params></methodResponse>`,
+		xmlEscape(result))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}